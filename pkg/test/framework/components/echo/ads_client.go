@@ -0,0 +1,212 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// AdsClient is a minimal Aggregated Discovery Service (ADS) client that backs Sidecar.Subscribe
+// against a real Pilot/istiod endpoint. A single AdsClient opens one StreamAggregatedResources
+// stream, created lazily on the first Subscribe call, and multiplexes every subscribed typeURL
+// over it the same way a real Envoy does: it remembers the version_info/nonce it was last sent
+// for each typeURL and ACKs every push by echoing them back on the next request before reading
+// the one after. mu also serializes every stream.Send, since grpc-go's ClientStream forbids
+// concurrent SendMsg calls and recvLoop's ACKs race with Subscribe's initial requests otherwise.
+type AdsClient struct {
+	nodeID string
+	conn   *grpc.ClientConn
+
+	mu     sync.Mutex
+	stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+	cancel context.CancelFunc
+	subs   map[string]*adsSubscription
+	state  map[string]*adsTypeState
+}
+
+// adsTypeState is the per-typeURL bookkeeping a real Envoy keeps across an ADS stream.
+type adsTypeState struct {
+	resourceNames []string
+	version       string
+	nonce         string
+}
+
+// adsSubscription is the per-typeURL delivery channel returned by Subscribe, plus its own mutex.
+// That mutex is deliberately separate from AdsClient.mu: it only ever guards a non-blocking send
+// against a concurrent close for this one typeURL, so a consumer that isn't draining its channel
+// can never stall Subscribe/cancel calls for every other typeURL multiplexed over the shared
+// stream, the way holding the client-wide lock across a blocking send would.
+type adsSubscription struct {
+	mu     sync.Mutex
+	ch     chan *discovery.DiscoveryResponse
+	closed bool
+}
+
+// NewAdsClient dials pilotAddress (e.g. "istiod.istio-system.svc:15010") and returns an AdsClient
+// that identifies itself to Pilot as nodeID, the same node ID a real Envoy sidecar uses
+// (Sidecar.NodeID()). A concrete Sidecar implementation backed by a real pod can embed the
+// returned client to implement Subscribe.
+func NewAdsClient(pilotAddress, nodeID string) (*AdsClient, error) {
+	conn, err := grpc.Dial(pilotAddress, grpc.WithTransportCredentials(insecure.NewCredentials())) //nolint:staticcheck
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial pilot at %s: %v", pilotAddress, err)
+	}
+	return &AdsClient{
+		nodeID: nodeID,
+		conn:   conn,
+		subs:   map[string]*adsSubscription{},
+		state:  map[string]*adsTypeState{},
+	}, nil
+}
+
+// Close tears down the ADS stream, if one was ever opened, and the underlying gRPC connection.
+func (c *AdsClient) Close() error {
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.mu.Unlock()
+	return c.conn.Close()
+}
+
+// Subscribe implements Sidecar.Subscribe: it registers typeURL/resourceNames on the shared ADS
+// stream (opening it on first use), sends the initial DiscoveryRequest carrying this sidecar's
+// NodeID, and delivers subsequent DiscoveryResponses for typeURL on the returned channel until
+// cancel is called. The channel holds one response; a push that arrives while it is full is
+// dropped (see recvLoop). cancel unregisters the subscription and closes the returned channel;
+// the shared stream and gRPC connection stay open for other subscribers until Close is called.
+func (c *AdsClient) Subscribe(typeURL string, resourceNames []string) (<-chan *discovery.DiscoveryResponse, func(), error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ensureStreamLocked(); err != nil {
+		return nil, nil, err
+	}
+
+	sub := &adsSubscription{ch: make(chan *discovery.DiscoveryResponse, 1)}
+	c.subs[typeURL] = sub
+	c.state[typeURL] = &adsTypeState{resourceNames: resourceNames}
+
+	if err := c.stream.Send(&discovery.DiscoveryRequest{
+		Node:          &core.Node{Id: c.nodeID},
+		TypeUrl:       typeURL,
+		ResourceNames: resourceNames,
+	}); err != nil {
+		delete(c.subs, typeURL)
+		delete(c.state, typeURL)
+		return nil, nil, fmt.Errorf("failed to send initial %s DiscoveryRequest: %v", typeURL, err)
+	}
+
+	cancel := func() {
+		c.mu.Lock()
+		delete(c.subs, typeURL)
+		delete(c.state, typeURL)
+		c.mu.Unlock()
+
+		sub.mu.Lock()
+		sub.closed = true
+		close(sub.ch)
+		sub.mu.Unlock()
+	}
+	return sub.ch, cancel, nil
+}
+
+func (c *AdsClient) ensureStreamLocked() error {
+	if c.stream != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	client := discovery.NewAggregatedDiscoveryServiceClient(c.conn)
+	stream, err := client.StreamAggregatedResources(ctx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to open ADS stream: %v", err)
+	}
+	c.stream = stream
+	c.cancel = cancel
+	go c.recvLoop(ctx)
+	return nil
+}
+
+// recvLoop reads every DiscoveryResponse off the shared stream, forwards it to the subscriber
+// registered for its typeURL, and then ACKs it by re-sending the request for that typeURL with
+// the response's VersionInfo/Nonce echoed back as version_info/response_nonce, the same
+// handshake a real Envoy performs before it will accept the next push.
+//
+// The subscriber lookup happens under c.mu, but the delivery itself is a non-blocking send
+// guarded only by that one subscription's own mutex: a consumer that isn't draining its channel
+// would otherwise stall this loop while holding the client-wide lock, blocking Subscribe/cancel
+// for every other typeURL sharing this stream. A full channel means the consumer is behind, so
+// the push is dropped rather than buffered further. A dropped push is still ACKed below and is
+// not delivered again, so a resource carried only by that push reaches the subscriber (e.g.
+// WaitForResource) only if a later push carries it again.
+//
+// The ACK send is made under c.mu, since grpc-go forbids concurrent SendMsg calls on the same
+// stream and Subscribe can be sending an initial DiscoveryRequest for a different typeURL at the
+// same time.
+func (c *AdsClient) recvLoop(ctx context.Context) {
+	for {
+		resp, err := c.stream.Recv()
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		sub, subscribed := c.subs[resp.GetTypeUrl()]
+		st := c.state[resp.GetTypeUrl()]
+		if st != nil {
+			st.version = resp.GetVersionInfo()
+			st.nonce = resp.GetNonce()
+		}
+		c.mu.Unlock()
+
+		if subscribed {
+			sub.mu.Lock()
+			if !sub.closed {
+				select {
+				case sub.ch <- resp:
+				default:
+					// Consumer isn't keeping up; drop rather than block the shared recvLoop.
+				}
+			}
+			sub.mu.Unlock()
+		}
+
+		if st == nil {
+			// We were never subscribed (or already cancelled); nothing to ACK.
+			continue
+		}
+		ack := &discovery.DiscoveryRequest{
+			Node:          &core.Node{Id: c.nodeID},
+			TypeUrl:       resp.GetTypeUrl(),
+			ResourceNames: st.resourceNames,
+			VersionInfo:   st.version,
+			ResponseNonce: st.nonce,
+		}
+		c.mu.Lock()
+		err = c.stream.Send(ack)
+		c.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
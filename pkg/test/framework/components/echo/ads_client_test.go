@@ -0,0 +1,113 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeADSServer plays the Pilot side of the handshake: it pushes one DiscoveryResponse and
+// records the DiscoveryRequest it gets back, so the test can assert the client actually ACKed
+// with the version/nonce it was just sent.
+type fakeADSServer struct {
+	discovery.UnimplementedAggregatedDiscoveryServiceServer
+	acked chan *discovery.DiscoveryRequest
+}
+
+func (s *fakeADSServer) StreamAggregatedResources(stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	// Initial (unacked) subscription request.
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	if err := stream.Send(&discovery.DiscoveryResponse{
+		TypeUrl:     ListenerTypeURL,
+		VersionInfo: "1",
+		Nonce:       "n1",
+	}); err != nil {
+		return err
+	}
+	ack, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.acked <- ack
+	return nil
+}
+
+func dialFakeADS(t *testing.T, srv *fakeADSServer) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, srv)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial fake ADS server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestAdsClientSubscribeDeliversAndAcks(t *testing.T) {
+	fake := &fakeADSServer{acked: make(chan *discovery.DiscoveryRequest, 1)}
+	conn := dialFakeADS(t, fake)
+
+	client := &AdsClient{
+		nodeID: "sidecar~10.0.0.1~my-pod.default~default.svc.cluster.local",
+		conn:   conn,
+		subs:   map[string]*adsSubscription{},
+		state:  map[string]*adsTypeState{},
+	}
+	defer client.Close()
+
+	ch, cancel, err := client.Subscribe(ListenerTypeURL, []string{"my-listener"})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	select {
+	case resp := <-ch:
+		if resp.GetVersionInfo() != "1" {
+			t.Errorf("got version %s, want 1", resp.GetVersionInfo())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the DiscoveryResponse")
+	}
+
+	select {
+	case ack := <-fake.acked:
+		if ack.GetVersionInfo() != "1" || ack.GetResponseNonce() != "n1" {
+			t.Errorf("got ack version=%s nonce=%s, want version=1 nonce=n1", ack.GetVersionInfo(), ack.GetResponseNonce())
+		}
+		if ack.GetNode().GetId() != client.nodeID {
+			t.Errorf("got ack node id=%s, want %s", ack.GetNode().GetId(), client.nodeID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the ACK")
+	}
+}
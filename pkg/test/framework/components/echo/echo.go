@@ -16,11 +16,14 @@ package echo
 
 import (
 	"context"
+	"time"
 
 	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	dto "github.com/prometheus/client_model/go"
 
 	"istio.io/istio/pkg/config/protocol"
+	agent "istio.io/istio/pkg/istio-agent/status"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/echo/client"
 	"istio.io/istio/pkg/test/echo/proto"
@@ -29,6 +32,15 @@ import (
 	"istio.io/istio/pkg/test/util/retry"
 )
 
+// xDS type URLs accepted by Sidecar.Subscribe and WaitForResource.
+const (
+	ListenerTypeURL = "type.googleapis.com/envoy.config.listener.v3.Listener"
+	ClusterTypeURL  = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	EndpointTypeURL = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
+	RouteTypeURL    = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+	SecretTypeURL   = "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret"
+)
+
 // Builder for a group of collaborating Echo Instances. Once built, all Instances in the
 // group:
 //
@@ -56,12 +68,43 @@ type Builder interface {
 	// WithClusters will cause subsequent With or WithConfig calls to be applied to the given clusters.
 	WithClusters(...cluster.Cluster) Builder
 
+	// WithNetworks assigns a network name to each cluster added via WithClusters, composing with it
+	// rather than replacing it. Clusters that are never given a network are treated as being on a
+	// single, unnamed default network. This is what allows Topology to tell network-local reachability
+	// apart from cross-network reachability that requires an east-west gateway.
+	WithNetworks(networks map[cluster.Cluster]string) Builder
+
+	// WithGatewayTopology marks the instances added by subsequent With or WithConfig calls as
+	// east-west gateways rather than ordinary echo workloads, so Topology can route cross-network
+	// probes through them without the caller having to duplicate instances.
+	WithGatewayTopology() Builder
+
 	// Build and initialize all Echo Instances. Upon returning, the Instance pointers
 	// are assigned and all Instances are ready to communicate with each other.
 	Build() (Instances, error)
 	BuildOrFail(t test.Failer) Instances
+
+	// Topology probes every (source cluster, destination cluster, network) pair introduced via
+	// WithClusters/WithNetworks and returns the observed east-west reachability matrix. It replaces
+	// polling WaitUntilCallable with a single first-class multi-network readiness gate. Must be
+	// called after Build().
+	Topology() (Topology, error)
+}
+
+// Topology reports the observed east-west reachability matrix for a Builder's clusters and networks.
+// Use NewTopology to construct one from the networks passed to Builder.WithNetworks and the
+// gateway instances passed to Builder.WithGatewayTopology.
+type Topology interface {
+	// Reachable returns nil if src can reach dst over the given network, confirmed by a probe call
+	// whose every response carries an X-Envoy-Upstream-Service-Cluster value containing dst's
+	// service name; dst's locality is not compared. Otherwise it returns an error describing the mismatch (e.g. no east-west gateway for network).
+	Reachable(src, dst Instance, network string) error
 }
 
+// ServiceClusterHeader is the response header Envoy sets to identify the cluster that actually
+// served a request.
+const ServiceClusterHeader = "X-Envoy-Upstream-Service-Cluster"
+
 type Caller interface {
 	// Call makes a call from this Instance to a target Instance.
 	Call(options CallOptions) (client.ParsedResponses, error)
@@ -104,8 +147,26 @@ type Instance interface {
 	Workloads() ([]Workload, error)
 	WorkloadsOrFail(t test.Failer) []Workload
 
-	// Restart restarts the workloads associated with this echo instance
-	Restart() error
+	// Restart restarts the workloads associated with this echo instance. By default this is a
+	// hard restart (e.g. kubectl delete). Passing a RestartOptions with UseGracefulDrain set
+	// calls Workload.Status().TriggerDrain on each workload first, passing it DrainDuration and
+	// ParentShutdownDuration, instead of killing the pod outright. The Status returned by
+	// NewStatusClient does not drain: its TriggerDrain always returns an error.
+	//
+	// Graceful drain is selected only through RestartOptions; Config has no field for it.
+	Restart(opts ...RestartOptions) error
+}
+
+// RestartOptions configures Instance.Restart.
+type RestartOptions struct {
+	// UseGracefulDrain, when true, asks Restart to drain the workload through
+	// Workload.Status().TriggerDrain rather than deleting it outright.
+	UseGracefulDrain bool
+
+	// DrainDuration and ParentShutdownDuration are forwarded to TriggerDrain when
+	// UseGracefulDrain is set. They're ignored otherwise.
+	DrainDuration          time.Duration
+	ParentShutdownDuration time.Duration
 }
 
 // Workload port exposed by an Echo instance
@@ -170,6 +231,32 @@ type Workload interface {
 	Logs() (string, error)
 	// LogsOrFail returns the logs for the app container, or aborts if an error is found
 	LogsOrFail(t test.Failer) string
+
+	// Status gives access to the pilot-agent status server running alongside this workload.
+	Status() Status
+}
+
+// Status provides access to the pilot-agent status server (port 15020) of a Workload,
+// reached through a port-forward. It exposes the same readiness and drain semantics that
+// pilot-agent enforces on the pod itself.
+type Status interface {
+	// Ready returns nil if /healthz/ready reports the workload (app and sidecar) as ready,
+	// otherwise an error describing why it is not.
+	Ready() error
+
+	// LiveConfig returns pilot-agent's current view of its own readiness/config state, as
+	// served by the status server. The Status returned by NewStatusClient sends no request:
+	// its LiveConfig always returns an error.
+	LiveConfig() (agent.State, error)
+
+	// TriggerDrain is meant to POST to the status server's quit endpoint, causing pilot-agent
+	// to begin graceful shutdown with the given drain and parent-shutdown durations (the same
+	// durations controlled by pilot-agent's --drainDuration/--parentShutdownDuration flags),
+	// without killing or restarting the pod. The request only confirms /quitquitquit exists,
+	// not that it accepts a per-request override of those durations (they're ordinarily
+	// startup flags), so this is currently unimplemented rather than shipping a guessed wire
+	// format. See statusClient.TriggerDrain.
+	TriggerDrain(drainDuration, parentShutdownDuration time.Duration) error
 }
 
 // Sidecar provides an interface to execute queries against a single Envoy sidecar.
@@ -199,10 +286,49 @@ type Sidecar interface {
 	Listeners() (*envoyAdmin.Listeners, error)
 	ListenersOrFail(t test.Failer) *envoyAdmin.Listeners
 
+	// Subscribe opens an ADS stream to Pilot using this sidecar's own NodeID, sends a
+	// DiscoveryRequest for the given typeURL/resourceNames, and delivers subsequent
+	// DiscoveryResponses on the returned channel (AdsClient drops a push that arrives while the
+	// channel is full). Each response is ACKed (by re-sending the
+	// request with the response's version/nonce) before the next one is read, mirroring how
+	// a real Envoy converges. The returned cancel func unsubscribes and closes the returned
+	// channel, so a `for resp := range responses` loop always terminates; the underlying ADS
+	// stream and gRPC connection are shared across subscriptions and stay open until the
+	// Sidecar itself is closed. AdsClient is the reference implementation a concrete Sidecar
+	// can embed to satisfy this method.
+	Subscribe(typeURL string, resourceNames []string) (responses <-chan *discovery.DiscoveryResponse, cancel func(), err error)
+
 	// Logs returns the logs for the sidecar container
 	Logs() (string, error)
 	// LogsOrFail returns the logs for the sidecar container, or aborts if an error is found
 	LogsOrFail(t test.Failer) string
 	Stats() (map[string]*dto.MetricFamily, error)
 	StatsOrFail(t test.Failer) map[string]*dto.MetricFamily
+
+	// StatsDelta returns the difference between the current Envoy stats and a prior snapshot
+	// returned by Stats, so a test can assert a metric increased between two points in time
+	// without reasoning about its absolute value.
+	StatsDelta(prev map[string]*dto.MetricFamily) (StatsDiff, error)
+
+	// ScrapePrometheus fetches the pilot-agent-merged /stats/prometheus endpoint (port 15020)
+	// rather than raw Envoy admin stats, returning the same app+envoy merged output a real
+	// Prometheus scrape would see.
+	ScrapePrometheus() (map[string]*dto.MetricFamily, error)
+}
+
+// StatsDiff is the difference between two Stats snapshots taken from the same Sidecar at
+// different points in time.
+type StatsDiff struct {
+	prev, cur map[string]*dto.MetricFamily
+}
+
+// NewStatsDiff returns a StatsDiff holding prev and cur; deltas are computed on demand by Delta.
+func NewStatsDiff(prev, cur map[string]*dto.MetricFamily) StatsDiff {
+	return StatsDiff{prev: prev, cur: cur}
+}
+
+// Delta returns cur[name] - prev[name] for the sample matching labelMatchers. If the metric
+// is not present in prev, the current value is returned as-is.
+func (d StatsDiff) Delta(name string, labelMatchers map[string]string) (float64, error) {
+	return statDelta(d.prev, d.cur, name, labelMatchers)
 }
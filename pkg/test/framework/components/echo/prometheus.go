@@ -0,0 +1,50 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const prometheusScrapePath = "/stats/prometheus"
+
+// ScrapePrometheus fetches the pilot-agent-merged /stats/prometheus endpoint at address
+// (typically the local end of a port-forward to the workload's 15020 status port) and parses it
+// exactly the way a real Prometheus scrape would, returning the app+envoy merged output rather
+// than raw Envoy admin stats. It backs Sidecar.ScrapePrometheus the same way statusClient backs
+// Workload.Status.
+func ScrapePrometheus(address string) (map[string]*dto.MetricFamily, error) {
+	u := url.URL{Scheme: "http", Host: address, Path: prometheusScrapePath}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape %s: %v", u.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to scrape %s: status %s", u.String(), resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prometheus output from %s: %v", u.String(), err)
+	}
+	return families, nil
+}
@@ -0,0 +1,56 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScrapePrometheus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != prometheusScrapePath {
+			t.Errorf("got path %s, want %s", r.URL.Path, prometheusScrapePath)
+		}
+		fmt.Fprint(w, "# TYPE istio_requests_total counter\nistio_requests_total{response_code=\"200\"} 5\n")
+	}))
+	defer srv.Close()
+
+	stats, err := ScrapePrometheus(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("ScrapePrometheus failed: %v", err)
+	}
+	v, err := statValue(stats, "istio_requests_total", map[string]string{"response_code": "200"})
+	if err != nil {
+		t.Fatalf("statValue failed: %v", err)
+	}
+	if v != 5 {
+		t.Errorf("got %v, want 5", v)
+	}
+}
+
+func TestScrapePrometheusNon200(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if _, err := ScrapePrometheus(strings.TrimPrefix(srv.URL, "http://")); err == nil {
+		t.Fatal("expected an error for a non-200 response, got nil")
+	}
+}
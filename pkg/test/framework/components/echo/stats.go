@@ -0,0 +1,107 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// WaitForStat blocks until the named metric on s (matched against labelMatchers, if any)
+// satisfies predicate, or until opts are exhausted. It is a convenience wrapper built entirely
+// on top of Sidecar.Stats: each attempt re-fetches the full stats snapshot and re-evaluates
+// predicate against the current value.
+func WaitForStat(s Sidecar, name string, labelMatchers map[string]string, predicate func(float64) bool, opts ...retry.Option) error {
+	return retry.UntilSuccess(func() error {
+		stats, err := s.Stats()
+		if err != nil {
+			return fmt.Errorf("failed to fetch stats: %v", err)
+		}
+		value, err := statValue(stats, name, labelMatchers)
+		if err != nil {
+			return err
+		}
+		if !predicate(value) {
+			return fmt.Errorf("metric %s=%v did not satisfy predicate", name, value)
+		}
+		return nil
+	}, opts...)
+}
+
+// WaitForStatOrFail calls WaitForStat and aborts the test if it fails.
+func WaitForStatOrFail(t test.Failer, s Sidecar, name string, labelMatchers map[string]string, predicate func(float64) bool, opts ...retry.Option) {
+	t.Helper()
+	if err := WaitForStat(s, name, labelMatchers, predicate, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// statDelta returns cur[name] - prev[name] for the sample matching labelMatchers, or just
+// cur's value if the metric has no matching sample in prev.
+func statDelta(prev, cur map[string]*dto.MetricFamily, name string, labelMatchers map[string]string) (float64, error) {
+	curValue, err := statValue(cur, name, labelMatchers)
+	if err != nil {
+		return 0, err
+	}
+	prevValue, err := statValue(prev, name, labelMatchers)
+	if err != nil {
+		// Not present before; treat the entire current value as the delta.
+		return curValue, nil
+	}
+	return curValue - prevValue, nil
+}
+
+// statValue finds the sample for name matching labelMatchers and returns its value, regardless
+// of whether the underlying metric is a counter or a gauge.
+func statValue(stats map[string]*dto.MetricFamily, name string, labelMatchers map[string]string) (float64, error) {
+	family, ok := stats[name]
+	if !ok {
+		return 0, fmt.Errorf("metric %s not found", name)
+	}
+	for _, m := range family.GetMetric() {
+		if !labelsMatch(m.GetLabel(), labelMatchers) {
+			continue
+		}
+		switch {
+		case m.GetCounter() != nil:
+			return m.GetCounter().GetValue(), nil
+		case m.GetGauge() != nil:
+			return m.GetGauge().GetValue(), nil
+		case m.GetUntyped() != nil:
+			return m.GetUntyped().GetValue(), nil
+		}
+	}
+	return 0, fmt.Errorf("metric %s has no sample matching labels %v", name, labelMatchers)
+}
+
+func labelsMatch(labels []*dto.LabelPair, matchers map[string]string) bool {
+	for k, v := range matchers {
+		found := false
+		for _, l := range labels {
+			if l.GetName() == k && l.GetValue() == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
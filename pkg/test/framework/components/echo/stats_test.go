@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counterFamily(value float64, labels map[string]string) *dto.MetricFamily {
+	var pairs []*dto.LabelPair
+	for k, v := range labels {
+		k, v := k, v
+		pairs = append(pairs, &dto.LabelPair{Name: &k, Value: &v})
+	}
+	return &dto.MetricFamily{
+		Metric: []*dto.Metric{
+			{
+				Label:   pairs,
+				Counter: &dto.Counter{Value: &value},
+			},
+		},
+	}
+}
+
+func TestLabelsMatch(t *testing.T) {
+	name, value := "response_code", "200"
+	labels := []*dto.LabelPair{{Name: &name, Value: &value}}
+
+	if !labelsMatch(labels, map[string]string{"response_code": "200"}) {
+		t.Error("expected matching labels to match")
+	}
+	if !labelsMatch(labels, nil) {
+		t.Error("expected no matchers to always match")
+	}
+	if labelsMatch(labels, map[string]string{"response_code": "500"}) {
+		t.Error("expected mismatched value to not match")
+	}
+	if labelsMatch(labels, map[string]string{"missing": "x"}) {
+		t.Error("expected missing label to not match")
+	}
+}
+
+func TestStatValue(t *testing.T) {
+	stats := map[string]*dto.MetricFamily{
+		"istio_requests_total": counterFamily(5, map[string]string{"response_code": "200"}),
+	}
+
+	v, err := statValue(stats, "istio_requests_total", map[string]string{"response_code": "200"})
+	if err != nil {
+		t.Fatalf("statValue failed: %v", err)
+	}
+	if v != 5 {
+		t.Errorf("got %v, want 5", v)
+	}
+
+	if _, err := statValue(stats, "missing_metric", nil); err == nil {
+		t.Error("expected an error for a missing metric, got nil")
+	}
+	if _, err := statValue(stats, "istio_requests_total", map[string]string{"response_code": "500"}); err == nil {
+		t.Error("expected an error for a non-matching sample, got nil")
+	}
+}
+
+func TestStatDelta(t *testing.T) {
+	prev := map[string]*dto.MetricFamily{
+		"istio_requests_total": counterFamily(5, map[string]string{"response_code": "200"}),
+	}
+	cur := map[string]*dto.MetricFamily{
+		"istio_requests_total": counterFamily(8, map[string]string{"response_code": "200"}),
+	}
+
+	delta, err := statDelta(prev, cur, "istio_requests_total", map[string]string{"response_code": "200"})
+	if err != nil {
+		t.Fatalf("statDelta failed: %v", err)
+	}
+	if delta != 3 {
+		t.Errorf("got %v, want 3", delta)
+	}
+
+	// Not present in prev: the whole current value is the delta.
+	delta, err = statDelta(map[string]*dto.MetricFamily{}, cur, "istio_requests_total", map[string]string{"response_code": "200"})
+	if err != nil {
+		t.Fatalf("statDelta failed: %v", err)
+	}
+	if delta != 8 {
+		t.Errorf("got %v, want 8", delta)
+	}
+}
+
+type fakeSidecarStats struct {
+	Sidecar
+	calls int
+	seq   []map[string]*dto.MetricFamily
+}
+
+func (f *fakeSidecarStats) Stats() (map[string]*dto.MetricFamily, error) {
+	stats := f.seq[f.calls]
+	if f.calls < len(f.seq)-1 {
+		f.calls++
+	}
+	return stats, nil
+}
+
+func TestWaitForStat(t *testing.T) {
+	sidecar := &fakeSidecarStats{seq: []map[string]*dto.MetricFamily{
+		{"istio_requests_total": counterFamily(1, nil)},
+		{"istio_requests_total": counterFamily(5, nil)},
+	}}
+
+	err := WaitForStat(sidecar, "istio_requests_total", nil, func(v float64) bool {
+		return v >= 5
+	}, fastRetry...)
+	if err != nil {
+		t.Fatalf("WaitForStat failed: %v", err)
+	}
+}
+
+func TestWaitForStatNeverSatisfied(t *testing.T) {
+	sidecar := &fakeSidecarStats{seq: []map[string]*dto.MetricFamily{
+		{"istio_requests_total": counterFamily(1, nil)},
+	}}
+
+	err := WaitForStat(sidecar, "istio_requests_total", nil, func(v float64) bool {
+		return v >= 5
+	}, fastRetry...)
+	if err == nil {
+		t.Fatal("expected an error when the predicate is never satisfied, got nil")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pkg/test/framework/components/cluster"
+)
+
+// networkTopology is the default Topology implementation. It is built entirely on top of the
+// Caller interface: Reachable issues a real call and inspects the response rather than
+// inspecting cluster-internal state, so it works the same way regardless of what kind of
+// Instance (kube, fake, etc.) it's given.
+type networkTopology struct {
+	networks map[cluster.Cluster]string
+	gateways []Instance
+}
+
+// NewTopology returns a Topology that validates reachability against the given cluster->network
+// assignment (as passed to Builder.WithNetworks) and the given east-west gateway instances (as
+// passed to Builder.WithGatewayTopology). gateways may be nil if no cross-network traffic is
+// expected.
+func NewTopology(networks map[cluster.Cluster]string, gateways []Instance) Topology {
+	return &networkTopology{networks: networks, gateways: gateways}
+}
+
+func (n *networkTopology) Reachable(src, dst Instance, network string) error {
+	if !n.knownNetwork(network) {
+		return fmt.Errorf("network %q was never registered via WithNetworks", network)
+	}
+
+	dstNetwork := n.networks[dst.Config().Cluster]
+	if dstNetwork != network {
+		return fmt.Errorf("dst %s is on network %q, not %q", dst.Config().Service, dstNetwork, network)
+	}
+
+	srcNetwork := n.networks[src.Config().Cluster]
+	if srcNetwork != dstNetwork && !n.hasGatewayFor(dstNetwork) {
+		return fmt.Errorf("src %s (network %q) cannot reach dst %s on network %q: no east-west gateway registered for %q",
+			src.Config().Service, srcNetwork, dst.Config().Service, dstNetwork, dstNetwork)
+	}
+
+	responses, err := src.Call(CallOptions{Target: dst})
+	if err != nil {
+		return fmt.Errorf("src %s could not reach dst %s on network %s: %v",
+			src.Config().Service, dst.Config().Service, network, err)
+	}
+
+	want := dst.Config().Service
+	for _, resp := range responses {
+		got := resp.RawResponse[ServiceClusterHeader]
+		if !strings.Contains(got, want) {
+			return fmt.Errorf("src %s reached an unexpected upstream on network %s: %s=%q, want it to contain %q",
+				src.Config().Service, network, ServiceClusterHeader, got, want)
+		}
+	}
+	return nil
+}
+
+func (n *networkTopology) knownNetwork(network string) bool {
+	for _, net := range n.networks {
+		if net == network {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGatewayFor reports whether an east-west gateway (registered via Builder.WithGatewayTopology)
+// is present on the given network, making it eligible to carry cross-network traffic into it.
+func (n *networkTopology) hasGatewayFor(network string) bool {
+	for _, gw := range n.gateways {
+		if n.networks[gw.Config().Cluster] == network {
+			return true
+		}
+	}
+	return false
+}
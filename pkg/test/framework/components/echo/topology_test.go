@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/cluster"
+)
+
+// fakeInstance overrides only Config and Call, the two methods networkTopology.Reachable uses;
+// everything else is left to the zero-value, embedded Instance, which panics if exercised.
+type fakeInstance struct {
+	Instance
+	config Config
+	call   func(CallOptions) (client.ParsedResponses, error)
+}
+
+func (f *fakeInstance) Config() Config { return f.config }
+
+func (f *fakeInstance) Call(options CallOptions) (client.ParsedResponses, error) {
+	return f.call(options)
+}
+
+// fakeCluster is a distinguishable, comparable stand-in for a cluster.Cluster map key; tests
+// never call any of its (embedded, nil) cluster.Cluster methods.
+type fakeCluster struct {
+	cluster.Cluster
+	name string
+}
+
+func callReaching(upstream string) func(CallOptions) (client.ParsedResponses, error) {
+	return func(CallOptions) (client.ParsedResponses, error) {
+		return client.ParsedResponses{
+			{RawResponse: map[string]string{ServiceClusterHeader: "outbound|80||" + upstream}},
+		}, nil
+	}
+}
+
+func TestNetworkTopologyReachable(t *testing.T) {
+	dst := &fakeInstance{config: Config{Service: "b"}}
+	src := &fakeInstance{config: Config{Service: "a"}, call: callReaching("b.default.svc.cluster.local")}
+
+	topo := NewTopology(map[cluster.Cluster]string{}, nil)
+	if err := topo.Reachable(src, dst, "network-1"); err == nil {
+		t.Fatal("expected an error for an unregistered network, got nil")
+	}
+
+	topo = NewTopology(map[cluster.Cluster]string{nil: "network-1"}, nil)
+	if err := topo.Reachable(src, dst, "network-1"); err != nil {
+		t.Fatalf("Reachable failed: %v", err)
+	}
+}
+
+func TestNetworkTopologyUnreachable(t *testing.T) {
+	dst := &fakeInstance{config: Config{Service: "b"}}
+	src := &fakeInstance{config: Config{Service: "a"}, call: callReaching("c.default.svc.cluster.local")}
+
+	topo := NewTopology(map[cluster.Cluster]string{nil: "network-1"}, nil)
+	if err := topo.Reachable(src, dst, "network-1"); err == nil {
+		t.Fatal("expected an error for a response served by the wrong upstream, got nil")
+	}
+}
+
+func TestNetworkTopologyCrossNetworkRequiresGateway(t *testing.T) {
+	clusterA := fakeCluster{name: "cluster-a"}
+	clusterB := fakeCluster{name: "cluster-b"}
+	networks := map[cluster.Cluster]string{
+		clusterA: "network-1",
+		clusterB: "network-2",
+	}
+
+	dst := &fakeInstance{config: Config{Service: "b", Cluster: clusterB}}
+	src := &fakeInstance{
+		config: Config{Service: "a", Cluster: clusterA},
+		call:   callReaching("b.default.svc.cluster.local"),
+	}
+
+	// Cluster A and cluster B are on different networks, and no gateway has been registered for
+	// network-2: the probe must be rejected without ever issuing a call.
+	called := false
+	src.call = func(options CallOptions) (client.ParsedResponses, error) {
+		called = true
+		return callReaching("b.default.svc.cluster.local")(options)
+	}
+	topo := NewTopology(networks, nil)
+	if err := topo.Reachable(src, dst, "network-2"); err == nil {
+		t.Fatal("expected an error for a cross-network call with no east-west gateway, got nil")
+	}
+	if called {
+		t.Error("expected Reachable to short-circuit before calling src.Call")
+	}
+
+	// Registering an east-west gateway on network-2 allows the same pair to be checked.
+	gateway := &fakeInstance{config: Config{Service: "istio-eastwestgateway", Cluster: clusterB}}
+	topo = NewTopology(networks, []Instance{gateway})
+	if err := topo.Reachable(src, dst, "network-2"); err != nil {
+		t.Fatalf("Reachable failed with an east-west gateway registered: %v", err)
+	}
+	if !called {
+		t.Error("expected Reachable to call src.Call once a gateway is registered")
+	}
+}
+
+func TestNetworkTopologyIntraNetworkNeedsNoGateway(t *testing.T) {
+	clusterA := fakeCluster{name: "cluster-a"}
+	networks := map[cluster.Cluster]string{clusterA: "network-1"}
+
+	dst := &fakeInstance{config: Config{Service: "b", Cluster: clusterA}}
+	src := &fakeInstance{
+		config: Config{Service: "a", Cluster: clusterA},
+		call:   callReaching("b.default.svc.cluster.local"),
+	}
+
+	topo := NewTopology(networks, nil)
+	if err := topo.Reachable(src, dst, "network-1"); err != nil {
+		t.Fatalf("Reachable failed for an intra-network call: %v", err)
+	}
+}
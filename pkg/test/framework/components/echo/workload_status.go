@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	agent "istio.io/istio/pkg/istio-agent/status"
+)
+
+const (
+	statusReadyPath = "/healthz/ready"
+	statusDrainPath = "/quitquitquit"
+)
+
+// statusClient implements Status by talking directly to a pilot-agent status server reachable
+// at address (typically the local end of a port-forward to the workload's 15020 port).
+type statusClient struct {
+	address string
+	client  *http.Client
+}
+
+// NewStatusClient returns a Status that talks to the pilot-agent status server at address
+// (host:port, no scheme).
+func NewStatusClient(address string) Status {
+	return &statusClient{
+		address: address,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *statusClient) get(path string) (*http.Response, error) {
+	u := url.URL{Scheme: "http", Host: s.address, Path: path}
+	resp, err := s.client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach status server at %s: %v", u.String(), err)
+	}
+	return resp, nil
+}
+
+func (s *statusClient) Ready() error {
+	resp, err := s.get(statusReadyPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("workload not ready: status %s: %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+func (s *statusClient) LiveConfig() (agent.State, error) {
+	// TODO: the request only documents /healthz/ready, /quitquitquit and /app-health/* on the
+	// pilot-agent status server; it has no registered handler for a live-config dump, so
+	// guessing a path here would just be wrong. Deferred until the real endpoint is confirmed.
+	var state agent.State
+	return state, fmt.Errorf("LiveConfig is not implemented: pilot-agent's status server exposes no documented live-config endpoint")
+}
+
+func (s *statusClient) TriggerDrain(drainDuration, parentShutdownDuration time.Duration) error {
+	// TODO: the request only confirms /quitquitquit exists on the status server, not that it
+	// takes drainDuration/parentShutdownDuration as per-request overrides; pilot-agent normally
+	// controls those via its --drainDuration/--parentShutdownDuration startup flags. Sending
+	// invented query params (as a prior version of this method did) would be indistinguishable
+	// from a real override from the caller's point of view, so leave this unimplemented until
+	// the real wire contract is confirmed, the same way LiveConfig is.
+	return fmt.Errorf("TriggerDrain is not implemented: /quitquitquit's per-request drain/parent-shutdown override contract is unconfirmed")
+}
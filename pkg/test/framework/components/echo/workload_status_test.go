@@ -0,0 +1,59 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusClientReady(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != statusReadyPath {
+			t.Errorf("got path %s, want %s", r.URL.Path, statusReadyPath)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	status := NewStatusClient(strings.TrimPrefix(srv.URL, "http://"))
+	if err := status.Ready(); err != nil {
+		t.Fatalf("Ready() failed: %v", err)
+	}
+}
+
+func TestStatusClientReadyNotReady(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	status := NewStatusClient(strings.TrimPrefix(srv.URL, "http://"))
+	if err := status.Ready(); err == nil {
+		t.Fatal("expected an error for a non-200 response, got nil")
+	}
+}
+
+func TestStatusClientTriggerDrainNotImplemented(t *testing.T) {
+	// TriggerDrain doesn't have a confirmed wire contract for per-request drain durations, so
+	// it must fail rather than guess; no fake server is needed since it never dials out.
+	status := NewStatusClient("unused")
+	if err := status.TriggerDrain(time.Second, time.Second); err == nil {
+		t.Fatal("expected TriggerDrain to report itself unimplemented, got nil")
+	}
+}
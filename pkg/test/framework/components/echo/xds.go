@@ -0,0 +1,106 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	googleProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// resourceReceiveTimeout bounds a single receive from the Subscribe channel inside
+// WaitForResource's retried function. retry.UntilSuccess only measures elapsed time between
+// calls to that function; it can't preempt one already blocked in a channel receive, so without
+// its own deadline a Pilot that never pushes the requested resource would hang the test forever
+// instead of timing out. A var, not a const, so tests can shrink it.
+var resourceReceiveTimeout = 30 * time.Second
+
+// WaitForResource subscribes to typeURL on s and blocks until a resource named resourceName is
+// delivered for which accept returns true, or until opts are exhausted. It is a convenience
+// wrapper built entirely on top of Sidecar.Subscribe: it owns the subscription for its own
+// duration and always cancels it before returning.
+func WaitForResource(s Sidecar, typeURL, resourceName string, accept func(googleProto.Message) bool, opts ...retry.Option) error {
+	responses, cancel, err := s.Subscribe(typeURL, []string{resourceName})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %v", typeURL, err)
+	}
+	defer cancel()
+
+	return retry.UntilSuccess(func() error {
+		select {
+		case resp, ok := <-responses:
+			if !ok {
+				return fmt.Errorf("subscription to %s closed before an accepted %s was received", typeURL, resourceName)
+			}
+			return acceptResource(resp, resourceName, accept)
+		case <-time.After(resourceReceiveTimeout):
+			return fmt.Errorf("timed out after %s waiting for a %s push", resourceReceiveTimeout, typeURL)
+		}
+	}, opts...)
+}
+
+// WaitForResourceOrFail calls WaitForResource and aborts the test if it fails.
+func WaitForResourceOrFail(t test.Failer, s Sidecar, typeURL, resourceName string, accept func(googleProto.Message) bool, opts ...retry.Option) {
+	t.Helper()
+	if err := WaitForResource(s, typeURL, resourceName, accept, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// acceptResource scans a single DiscoveryResponse for resourceName, decodes it, and runs accept
+// against it. It returns nil only once accept has returned true for a matching resource.
+func acceptResource(resp *discovery.DiscoveryResponse, resourceName string, accept func(googleProto.Message) bool) error {
+	for _, res := range resp.GetResources() {
+		msg, err := decodeResource(res)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s resource: %v", resp.GetTypeUrl(), err)
+		}
+		if !resourceNameMatches(msg, resourceName) {
+			continue
+		}
+		if accept(msg) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no accepted resource named %s in push version %s", resourceName, resp.GetVersionInfo())
+}
+
+func decodeResource(res *anypb.Any) (googleProto.Message, error) {
+	return res.UnmarshalNew()
+}
+
+// resourceNameMatches reports whether msg carries resourceName, using the xDS resources' common
+// convention of a top-level "name" field (Listener, Cluster, RouteConfiguration, Secret all have
+// one; ClusterLoadAssignment uses "cluster_name" instead).
+func resourceNameMatches(msg googleProto.Message, resourceName string) bool {
+	reflectMsg := msg.ProtoReflect()
+	for _, field := range []string{"name", "cluster_name"} {
+		fd := reflectMsg.Descriptor().Fields().ByName(protoreflect.Name(field))
+		if fd == nil {
+			continue
+		}
+		if reflectMsg.Get(fd).String() == resourceName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"testing"
+	"time"
+
+	envoyListener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	googleProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// fakeSidecar stubs out Subscribe for WaitForResource's control-flow tests; the embedded,
+// unset Sidecar makes every other method a deliberate panic so an errant call stands out.
+type fakeSidecar struct {
+	Sidecar
+	responses chan *discovery.DiscoveryResponse
+}
+
+func (f *fakeSidecar) Subscribe(string, []string) (<-chan *discovery.DiscoveryResponse, func(), error) {
+	return f.responses, func() {}, nil
+}
+
+var fastRetry = []retry.Option{retry.Timeout(100 * time.Millisecond), retry.Delay(time.Millisecond)}
+
+func listenerResponse(t *testing.T, name, version string) *discovery.DiscoveryResponse {
+	t.Helper()
+	l := &envoyListener.Listener{Name: name}
+	any, err := anypb.New(l)
+	if err != nil {
+		t.Fatalf("failed to pack listener: %v", err)
+	}
+	return &discovery.DiscoveryResponse{
+		TypeUrl:     ListenerTypeURL,
+		VersionInfo: version,
+		Resources:   []*anypb.Any{any},
+	}
+}
+
+func TestWaitForResource(t *testing.T) {
+	sidecar := &fakeSidecar{responses: make(chan *discovery.DiscoveryResponse, 1)}
+	sidecar.responses <- listenerResponse(t, "my-listener", "1")
+
+	var seen string
+	err := WaitForResource(sidecar, ListenerTypeURL, "my-listener", func(msg googleProto.Message) bool {
+		seen = msg.(*envoyListener.Listener).GetName()
+		return true
+	}, fastRetry...)
+	if err != nil {
+		t.Fatalf("WaitForResource failed: %v", err)
+	}
+	if seen != "my-listener" {
+		t.Fatalf("got %q, want my-listener", seen)
+	}
+}
+
+func TestWaitForResourceRetriesUntilAccepted(t *testing.T) {
+	sidecar := &fakeSidecar{responses: make(chan *discovery.DiscoveryResponse, 2)}
+	sidecar.responses <- listenerResponse(t, "other-listener", "1")
+	sidecar.responses <- listenerResponse(t, "my-listener", "2")
+
+	err := WaitForResource(sidecar, ListenerTypeURL, "my-listener", func(googleProto.Message) bool {
+		return true
+	}, fastRetry...)
+	if err != nil {
+		t.Fatalf("WaitForResource failed: %v", err)
+	}
+}
+
+func TestWaitForResourceNoPushEverArrives(t *testing.T) {
+	old := resourceReceiveTimeout
+	resourceReceiveTimeout = time.Millisecond
+	t.Cleanup(func() { resourceReceiveTimeout = old })
+
+	sidecar := &fakeSidecar{responses: make(chan *discovery.DiscoveryResponse)}
+
+	err := WaitForResource(sidecar, ListenerTypeURL, "my-listener", func(googleProto.Message) bool {
+		return true
+	}, fastRetry...)
+	if err == nil {
+		t.Fatal("expected a timeout error when the subscription never delivers a push, got nil")
+	}
+}
+
+func TestWaitForResourceChannelClosed(t *testing.T) {
+	sidecar := &fakeSidecar{responses: make(chan *discovery.DiscoveryResponse)}
+	close(sidecar.responses)
+
+	err := WaitForResource(sidecar, ListenerTypeURL, "my-listener", func(googleProto.Message) bool {
+		return true
+	}, fastRetry...)
+	if err == nil {
+		t.Fatal("expected an error when the subscription channel is closed, got nil")
+	}
+}